@@ -0,0 +1,164 @@
+// Package config loads and saves filerover's user configuration: keybinding
+// overrides, sort/display defaults, and per-directory overrides.
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Keymap holds the key bound to each configurable action. Values are the
+// same key strings bubbletea reports from tea.KeyMsg.String().
+type Keymap struct {
+	NavIn        string `yaml:"nav_in"`
+	NavOut       string `yaml:"nav_out"`
+	NavHome      string `yaml:"nav_home"`
+	Delete       string `yaml:"delete"`
+	Select       string `yaml:"select"`
+	ToggleHidden string `yaml:"toggle_hidden"`
+	UsageMode    string `yaml:"usage_mode"`
+	Preview      string `yaml:"preview"`
+	SortName     string `yaml:"sort_name"`
+	SortSize     string `yaml:"sort_size"`
+	SortTime     string `yaml:"sort_time"`
+	SortType     string `yaml:"sort_type"`
+}
+
+// DefaultKeymap matches the bindings filerover has always shipped with.
+func DefaultKeymap() Keymap {
+	return Keymap{
+		NavIn:        "right",
+		NavOut:       "left",
+		NavHome:      "~",
+		Delete:       "d",
+		Select:       " ",
+		ToggleHidden: ".",
+		UsageMode:    "u",
+		Preview:      "P",
+		SortName:     "1",
+		SortSize:     "2",
+		SortTime:     "3",
+		SortType:     "4",
+	}
+}
+
+// LocalRule overrides a subset of Config for directories under Pattern: a
+// glob (matched with filepath.Match) or, without wildcard characters, a
+// directory prefix. "~" at the start of Pattern expands to $HOME.
+type LocalRule struct {
+	Pattern  string `yaml:"pattern"`
+	SortBy   string `yaml:"sort,omitempty"`
+	DirFirst *bool  `yaml:"dirfirst,omitempty"`
+	Hidden   *bool  `yaml:"hidden,omitempty"`
+}
+
+// Config is the full contents of config.yaml.
+type Config struct {
+	Keymap     Keymap      `yaml:"keymap"`
+	SortBy     string      `yaml:"sort_by"`
+	DirFirst   bool        `yaml:"dirfirst"`
+	ShowHidden bool        `yaml:"hidden"`
+	SetLocal   []LocalRule `yaml:"set_local"`
+	PreviewCmd string      `yaml:"preview_cmd"`
+}
+
+// Default returns the configuration filerover uses when no config file is
+// present.
+func Default() Config {
+	return Config{
+		Keymap:     DefaultKeymap(),
+		SortBy:     "name",
+		DirFirst:   true,
+		ShowHidden: false,
+		PreviewCmd: "chafa",
+	}
+}
+
+// Path returns the config file location, honoring $XDG_CONFIG_HOME and
+// falling back to ~/.config. It returns "" if the home directory can't be
+// determined.
+func Path() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "filerover", "config.yaml")
+}
+
+// Load reads the config file at Path, returning Default() on top of which
+// any values present in the file are applied. A missing or unparsable file
+// is not an error: it just means the defaults are used.
+func Load() Config {
+	cfg := Default()
+
+	path := Path()
+	if path == "" {
+		return cfg
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Default()
+	}
+	return cfg
+}
+
+// Save writes cfg to Path as YAML, creating the parent directory if needed.
+func Save(cfg Config) error {
+	path := Path()
+	if path == "" {
+		return os.ErrInvalid
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LocalOverride returns the last set_local rule whose Pattern matches dir,
+// if any.
+func LocalOverride(cfg Config, dir string) (LocalRule, bool) {
+	var match LocalRule
+	found := false
+	for _, rule := range cfg.SetLocal {
+		pattern := expandHome(rule.Pattern)
+		if patternMatches(pattern, dir) {
+			match = rule
+			found = true
+		}
+	}
+	return match, found
+}
+
+func patternMatches(pattern, dir string) bool {
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, _ := filepath.Match(pattern, dir)
+		return ok
+	}
+	return dir == pattern || strings.HasPrefix(dir, pattern+string(os.PathSeparator))
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
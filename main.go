@@ -1,31 +1,83 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/charmbracelet/bubbles/filepicker"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+
+	"filerover/config"
+	"filerover/preview"
+	"filerover/scanner"
 )
 
-// Model represents the application state
+// Model represents the application state.
+//
+// Plain directory browsing is delegated to a bubbles filepicker, which owns
+// its own listing, cursor and viewport scrolling. The nav (search) and
+// disk-usage views render custom rows the filepicker can't express (match
+// highlighting, size bars, item counts), so they keep their own cursor/
+// offset bookkeeping over the same files/filteredFiles slices as before.
 type model struct {
+	picker       filepicker.Model
+	commandInput textinput.Model
+	searchField  textinput.Model
+
 	files         []os.DirEntry
-	currentDir    string
+	filteredFiles []os.DirEntry
 	cursor        int
-	input         string
-	err           error
-	height        int
 	offset        int
-	message       string
-	navMode       bool
-	searchInput   string
-	lastDir       string
-	sortBy        string
-	filteredFiles []os.DirEntry
+
+	currentDir string
+	lastDir    string
+	err        error
+	height     int
+	width      int
+	message    string
+	navMode    bool
+	sortBy     string
+	dirFirst   bool
+
+	usageMode     bool
+	scanCache     *scanner.Cache
+	scanProgress  chan scanner.Progress
+	scanDone      chan struct{}
+	scanResults   map[string]scanner.Result
+	scanning      bool
+	scannedCount  int
+	confirmDelete string
+
+	selected     map[string]bool
+	confirmBatch *batchOp
+
+	cfg        config.Config
+	showHidden bool
+
+	output    viewport.Model
+	outputBuf []string
+	running   *runningCmd
+
+	history      []string
+	historyIndex int // index into history currently shown; len(history) means "not browsing"
+	historyDraft string
+
+	previewMode    bool
+	previewCache   *preview.Cache
+	previewResult  preview.Result
+	previewLoading bool
 }
 
 // Styles for the UI
@@ -67,8 +119,38 @@ var (
 			Foreground(lipgloss.Color("#87CEEB")).
 			Background(lipgloss.Color("#2D2D2D")).
 			Padding(0, 1)
+
+	markedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#50C878")).
+			PaddingLeft(2)
 )
 
+// newPicker builds the filepicker used for plain directory browsing, wired
+// to the same configurable key bindings the rest of the app uses.
+func newPicker(currentDir string, cfg config.Config) filepicker.Model {
+	fp := filepicker.New()
+	fp.CurrentDirectory = currentDir
+	fp.DirAllowed = true
+	fp.FileAllowed = true
+	fp.ShowPermissions = true
+	fp.ShowSize = true
+	fp.ShowHidden = cfg.ShowHidden
+	fp.Height = 20
+	fp.KeyMap.Up = key.NewBinding(key.WithKeys("up"))
+	fp.KeyMap.Down = key.NewBinding(key.WithKeys("down"))
+	fp.KeyMap.Back = key.NewBinding(key.WithKeys(cfg.Keymap.NavOut))
+	fp.KeyMap.Open = key.NewBinding(key.WithKeys(cfg.Keymap.NavIn))
+	fp.KeyMap.Select = key.NewBinding() // command input owns "enter"
+	fp.Styles.Cursor = selectedStyle
+	fp.Styles.Directory = itemStyle.Foreground(lipgloss.Color("#7D56F4"))
+	fp.Styles.Symlink = itemStyle.Foreground(lipgloss.Color("#87CEEB"))
+	fp.Styles.File = itemStyle
+	fp.Styles.Permission = itemStyle.Foreground(lipgloss.Color("#626262"))
+	fp.Styles.Selected = selectedStyle
+	fp.Styles.FileSize = itemStyle.Foreground(lipgloss.Color("#626262"))
+	return fp
+}
+
 // Initial model
 func initialModel() model {
 	currentDir, err := os.Getwd()
@@ -81,21 +163,101 @@ func initialModel() model {
 		files = []os.DirEntry{}
 	}
 
-	return model{
-		files:         files,
-		currentDir:    currentDir,
-		cursor:        0,
-		input:         "",
-		err:           err,
-		height:        20, // Default height, will be updated on resize
-		offset:        0,
-		message:       "",
-		navMode:       false,
-		searchInput:   "",
-		lastDir:       "",
-		sortBy:        "name",
-		filteredFiles: files,
+	cfg := config.Load()
+	sortBy, dirFirst, showHidden := resolveLocal(cfg, currentDir)
+
+	commandInput := textinput.New()
+	commandInput.Prompt = "$ "
+	commandInput.Focus()
+
+	searchField := textinput.New()
+	searchField.Prompt = "Search: "
+
+	history := loadHistory()
+
+	m := model{
+		picker:       newPicker(currentDir, cfg),
+		commandInput: commandInput,
+		searchField:  searchField,
+		files:        files,
+		currentDir:   currentDir,
+		err:          err,
+		height:       20, // Default height, will be updated on resize
+		navMode:      false,
+		lastDir:      "",
+		sortBy:       sortBy,
+		dirFirst:     dirFirst,
+		scanCache:    scanner.NewCache(),
+		selected:     make(map[string]bool),
+		cfg:          cfg,
+		showHidden:   showHidden,
+		output:       viewport.New(80, 8),
+		history:      history,
+		historyIndex: len(history),
+		previewCache: preview.NewCache(previewCacheSize),
+	}
+	m.files = sortFiles(m.files, m.sortBy, nil, m.dirFirst)
+	m.filteredFiles = filterFiles(m.files, "", m.showHidden)
+	return m
+}
+
+// applySet interprets the argument to the ":set" command, e.g.
+// "dirfirst", "nodirfirst", "dirfirst!", "hidden!", or "sort size", vim-
+// style: a bare name sets it, a "no" prefix clears it, and a "!" suffix
+// toggles it. It mutates the running model and re-sorts m.files so the
+// change is visible immediately.
+func (m *model) applySet(arg string) {
+	switch {
+	case arg == "dirfirst" || arg == "dirfirst!" || arg == "nodirfirst":
+		if arg == "dirfirst!" {
+			m.dirFirst = !m.dirFirst
+		} else {
+			m.dirFirst = arg == "dirfirst"
+		}
+		m.cfg.DirFirst = m.dirFirst
+	case arg == "hidden" || arg == "hidden!" || arg == "nohidden":
+		if arg == "hidden!" {
+			m.showHidden = !m.showHidden
+		} else {
+			m.showHidden = arg == "hidden"
+		}
+		m.cfg.ShowHidden = m.showHidden
+		m.picker.ShowHidden = m.showHidden
+	case strings.HasPrefix(arg, "sort "):
+		sortBy := strings.TrimSpace(strings.TrimPrefix(arg, "sort "))
+		switch sortBy {
+		case "name", "size", "time", "type":
+			m.sortBy = sortBy
+			m.cfg.SortBy = sortBy
+		default:
+			m.message = fmt.Sprintf("Unknown sort %q", sortBy)
+			return
+		}
+	default:
+		m.message = fmt.Sprintf("Unknown set option %q", arg)
+		return
+	}
+	m.files = sortFiles(m.files, m.sortBy, m.scanResults, m.dirFirst)
+}
+
+// resolveLocal applies cfg's set_local override (if any) for dir on top of
+// cfg's global defaults.
+func resolveLocal(cfg config.Config, dir string) (sortBy string, dirFirst, showHidden bool) {
+	sortBy, dirFirst, showHidden = cfg.SortBy, cfg.DirFirst, cfg.ShowHidden
+	rule, ok := config.LocalOverride(cfg, dir)
+	if !ok {
+		return sortBy, dirFirst, showHidden
+	}
+	if rule.SortBy != "" {
+		sortBy = rule.SortBy
+	}
+	if rule.DirFirst != nil {
+		dirFirst = *rule.DirFirst
 	}
+	if rule.Hidden != nil {
+		showHidden = *rule.Hidden
+	}
+	return sortBy, dirFirst, showHidden
 }
 
 // Commands
@@ -116,81 +278,480 @@ func changeDir(dir string) tea.Cmd {
 	}
 }
 
+// scanResultMsg carries the completed recursive size scan of a directory.
+// done identifies which scan it came from, so a result from a scan that
+// was since abandoned (see startScan) can be told apart from the current
+// one instead of clobbering its state.
+type scanResultMsg struct {
+	dir     string
+	results map[string]scanner.Result
+	err     error
+	done    <-chan struct{}
+}
+
+// scanProgressMsg reports that the scanner has just sized another entry.
+type scanProgressMsg scanner.Progress
+
+// scanDir walks every direct child of dir recursively, reporting progress on
+// progress as it goes, and finally returns a scanResultMsg. done lets a
+// later, abandoned-scan cleanup unblock a progress send that has no reader
+// left; see scanner.WalkChildren.
+func scanDir(dir string, cache *scanner.Cache, progress chan scanner.Progress, done <-chan struct{}) tea.Cmd {
+	return func() tea.Msg {
+		results, err := scanner.WalkChildren(dir, cache, progress, done)
+		close(progress)
+		return scanResultMsg{dir: dir, results: results, err: err, done: done}
+	}
+}
+
+// waitForScanProgress listens for the next progress event from an
+// in-flight scan so the UI can re-render without blocking on the walk.
+func waitForScanProgress(progress chan scanner.Progress) tea.Cmd {
+	return func() tea.Msg {
+		p, ok := <-progress
+		if !ok {
+			return nil
+		}
+		return scanProgressMsg(p)
+	}
+}
+
+// previewCacheSize bounds how many rendered previews the preview pane keeps
+// around at once.
+const previewCacheSize = 128
+
+// previewResultMsg carries a rendered preview back for the file it was
+// computed from, so a stale render arriving after the cursor has moved on
+// can be discarded.
+type previewResultMsg struct {
+	path   string
+	result preview.Result
+}
+
+// loadPreview renders the preview for path in the background. imageCmd and
+// cache are threaded straight from the model so the command stays a pure
+// function of its arguments, the same shape as scanDir/changeDir.
+func loadPreview(path, imageCmd string, cache *preview.Cache) tea.Cmd {
+	return func() tea.Msg {
+		return previewResultMsg{path: path, result: preview.Render(path, imageCmd, cache)}
+	}
+}
+
+// deleteEntry removes path recursively, mirroring `rm -rf`.
+func deleteEntry(path string) tea.Cmd {
+	return func() tea.Msg {
+		err := os.RemoveAll(path)
+		return dirChangedMsgFromDelete(filepath.Dir(path), err)
+	}
+}
+
+// dirChangedMsgFromDelete re-reads the parent directory after a delete so
+// the listing (and any in-flight scan) reflects the removal.
+func dirChangedMsgFromDelete(dir string, delErr error) tea.Msg {
+	files, err := os.ReadDir(dir)
+	if err == nil {
+		err = delErr
+	}
+	return dirChangedMsg{dir: dir, files: files, err: err}
+}
+
+// batchOp is a cp!/mv!/rm! command awaiting y/N confirmation against the
+// current selection.
+type batchOp struct {
+	name  string // "cp!", "mv!" or "rm!"
+	paths []string
+	dest  string
+}
+
+// batchOpDoneMsg reports the outcome of a confirmed batch operation.
+type batchOpDoneMsg struct {
+	op     string
+	output string
+	err    error
+}
+
+// runBatchOp executes a confirmed cp!/mv!/rm! against every selected path.
+func runBatchOp(op *batchOp, workingDir string) tea.Cmd {
+	return func() tea.Msg {
+		var output strings.Builder
+		var lastErr error
+
+		for _, path := range op.paths {
+			switch op.name {
+			case "rm!":
+				if err := os.RemoveAll(path); err != nil {
+					lastErr = err
+				}
+			case "cp!":
+				out, err := executeCommand("cp", []string{"-r", path, op.dest}, workingDir)
+				output.WriteString(out)
+				if err != nil {
+					lastErr = err
+				}
+			case "mv!":
+				out, err := executeCommand("mv", []string{path, op.dest}, workingDir)
+				output.WriteString(out)
+				if err != nil {
+					lastErr = err
+				}
+			}
+		}
+
+		return batchOpDoneMsg{op: op.name, output: output.String(), err: lastErr}
+	}
+}
+
+// selectionStats returns how many selected paths still exist and their
+// combined size, for the "N selected, Xkb" status line.
+func selectionStats(selected map[string]bool) (count int, totalSize int64) {
+	for path := range selected {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		count++
+		totalSize += info.Size()
+	}
+	return count, totalSize
+}
+
 // Execute system command
 func executeCommand(command string, args []string, workingDir string) (string, error) {
 	cmd := exec.Command(command, args...)
 	cmd.Dir = workingDir
-	
+
 	output, err := cmd.CombinedOutput()
 	return string(output), err
 }
 
-// Sort files by different criteria
-func sortFiles(files []os.DirEntry, sortBy string) []os.DirEntry {
-	switch sortBy {
-	case "name":
-		// Sort by name (directories first, then files)
-		for i := 0; i < len(files)-1; i++ {
-			for j := i + 1; j < len(files); j++ {
-				if files[i].IsDir() && !files[j].IsDir() {
-					continue
-				} else if !files[i].IsDir() && files[j].IsDir() {
-					files[i], files[j] = files[j], files[i]
-				} else if files[i].Name() > files[j].Name() {
-					files[i], files[j] = files[j], files[i]
-				}
-			}
+// runningCmd tracks a system command started with runCommandAsync so its
+// output can be streamed into the viewport and Ctrl-C can cancel it.
+type runningCmd struct {
+	name   string // the binary, e.g. "find" — used to decide whether to refresh the listing
+	label  string // the full command line, for status messages
+	cancel context.CancelFunc
+	lines  chan string
+	done   chan error
+}
+
+// outputLineMsg carries one streamed line of stdout/stderr from a running
+// command. ok is false once the command's output is exhausted.
+type outputLineMsg struct {
+	line string
+	ok   bool
+}
+
+// commandDoneMsg reports that a foreground command (streamed into the
+// output pane) has exited.
+type commandDoneMsg struct {
+	cmd *runningCmd
+	err error
+}
+
+// runCommandAsync starts command in workingDir and returns a runningCmd
+// whose lines channel receives stdout/stderr as they're written and whose
+// done channel receives the final error (nil on success) once the process
+// exits or is cancelled.
+func runCommandAsync(command string, args []string, workingDir, label string) *runningCmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = workingDir
+
+	lines := make(chan string, 256)
+	done := make(chan error, 1)
+	rc := &runningCmd{name: command, label: label, cancel: cancel, lines: lines, done: done}
+
+	stdout, outErr := cmd.StdoutPipe()
+	stderr, errErr := cmd.StderrPipe()
+	if outErr != nil || errErr != nil {
+		cancel()
+		close(lines)
+		done <- fmt.Errorf("failed to attach to %s's output", command)
+		close(done)
+		return rc
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		close(lines)
+		done <- err
+		close(done)
+		return rc
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go streamLines(stdout, lines, &wg)
+	go streamLines(stderr, lines, &wg)
+
+	go func() {
+		wg.Wait()
+		err := cmd.Wait()
+		close(lines)
+		done <- err
+		close(done)
+	}()
+
+	return rc
+}
+
+// streamLines copies r line-by-line onto lines until EOF.
+func streamLines(r io.Reader, lines chan<- string, wg *sync.WaitGroup) {
+	defer wg.Done()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines <- scanner.Text()
+	}
+}
+
+// waitForOutputLine listens for the next streamed line from rc so the UI
+// can append it without blocking on the running command.
+func waitForOutputLine(rc *runningCmd) tea.Cmd {
+	return func() tea.Msg {
+		line, ok := <-rc.lines
+		return outputLineMsg{line: line, ok: ok}
+	}
+}
+
+// waitForCommandDone listens for rc's exit status once its output has been
+// fully drained.
+func waitForCommandDone(rc *runningCmd) tea.Cmd {
+	return func() tea.Msg {
+		return commandDoneMsg{cmd: rc, err: <-rc.done}
+	}
+}
+
+// mutatesFilesystem reports whether command is one whose output should
+// trigger a directory refresh once it completes.
+func mutatesFilesystem(command string) bool {
+	switch command {
+	case "touch", "mkdir", "rm", "rmdir", "cp", "mv":
+		return true
+	}
+	return false
+}
+
+// bgJobDoneMsg reports the outcome of an "&"-suffixed background job.
+type bgJobDoneMsg struct {
+	label  string
+	output string
+	err    error
+}
+
+// runBackgroundJob runs command to completion without streaming its output,
+// announcing the result in the status bar once it's done.
+func runBackgroundJob(command string, args []string, workingDir, label string) tea.Cmd {
+	return func() tea.Msg {
+		output, err := executeCommand(command, args, workingDir)
+		return bgJobDoneMsg{label: label, output: output, err: err}
+	}
+}
+
+// historyPath returns the command history file location, honoring
+// $XDG_STATE_HOME and falling back to ~/.local/state.
+func historyPath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
 		}
-	case "size":
-		// Sort by size (largest first)
-		for i := 0; i < len(files)-1; i++ {
-			for j := i + 1; j < len(files); j++ {
-				infoI, _ := files[i].Info()
-				infoJ, _ := files[j].Info()
-				if infoI.Size() < infoJ.Size() {
-					files[i], files[j] = files[j], files[i]
-				}
-			}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "filerover", "history")
+}
+
+// loadHistory reads the command history file, oldest entry first. A missing
+// file just means there's no history yet.
+func loadHistory() []string {
+	path := historyPath()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			history = append(history, line)
 		}
-	case "time":
-		// Sort by modification time (newest first)
-		for i := 0; i < len(files)-1; i++ {
-			for j := i + 1; j < len(files); j++ {
-				infoI, _ := files[i].Info()
-				infoJ, _ := files[j].Info()
-				if infoI.ModTime().Before(infoJ.ModTime()) {
-					files[i], files[j] = files[j], files[i]
-				}
-			}
+	}
+	return history
+}
+
+// appendHistory records cmd as the most recent command, both in memory and
+// in the history file, skipping immediate repeats.
+func (m *model) appendHistory(cmd string) {
+	if len(m.history) > 0 && m.history[len(m.history)-1] == cmd {
+		m.historyIndex = len(m.history)
+		return
+	}
+	m.history = append(m.history, cmd)
+	m.historyIndex = len(m.history)
+
+	path := historyPath()
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, cmd)
+}
+
+// browseHistory moves historyIndex by one entry in dir ("up" = older,
+// "down" = newer) and loads the resulting entry into the command input,
+// stashing the in-progress value the first time browsing starts so "down"
+// past the newest entry restores it.
+func (m *model) browseHistory(dir string) {
+	if len(m.history) == 0 {
+		return
+	}
+	if m.historyIndex == len(m.history) {
+		m.historyDraft = m.commandInput.Value()
+	}
+	switch dir {
+	case "up":
+		if m.historyIndex > 0 {
+			m.historyIndex--
 		}
-	case "type":
-		// Sort by type (directories first, then files alphabetically)
-		for i := 0; i < len(files)-1; i++ {
-			for j := i + 1; j < len(files); j++ {
-				if files[i].IsDir() && !files[j].IsDir() {
-					continue
-				} else if !files[i].IsDir() && files[j].IsDir() {
-					files[i], files[j] = files[j], files[i]
-				} else if files[i].Name() > files[j].Name() {
-					files[i], files[j] = files[j], files[i]
-				}
-			}
+	case "down":
+		if m.historyIndex < len(m.history) {
+			m.historyIndex++
 		}
 	}
-	return files
+	if m.historyIndex == len(m.history) {
+		m.commandInput.SetValue(m.historyDraft)
+	} else {
+		m.commandInput.SetValue(m.history[m.historyIndex])
+	}
+	m.commandInput.CursorEnd()
 }
 
-// Filter files based on search input
-func filterFiles(files []os.DirEntry, searchInput string) []os.DirEntry {
-	if searchInput == "" {
-		return files
+// executeOnSelection runs cmd with "{}" substituted for the selected paths
+// (appending them if "{}" doesn't appear), e.g. "!chmod 644 {}".
+func executeOnSelection(cmd string, selected map[string]bool, workingDir string) (string, error) {
+	paths := make([]string, 0, len(selected))
+	for path := range selected {
+		paths = append(paths, path)
 	}
-	
+	sort.Strings(paths)
+	joined := strings.Join(paths, " ")
+
+	if strings.Contains(cmd, "{}") {
+		cmd = strings.ReplaceAll(cmd, "{}", joined)
+	} else {
+		cmd = cmd + " " + joined
+	}
+
+	parts := strings.Fields(cmd)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	return executeCommand(parts[0], parts[1:], workingDir)
+}
+
+// sizeOf returns the size to sort by for a single entry: when usage holds a
+// recursive scan result for it, that total is used (so directories compare
+// by their whole-subtree size); otherwise it falls back to the entry's own
+// on-disk size.
+func sizeOf(file os.DirEntry, usage map[string]scanner.Result) int64 {
+	if usage != nil {
+		if r, ok := usage[file.Name()]; ok {
+			return r.Size
+		}
+	}
+	info, _ := file.Info()
+	if info == nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// formatSize renders n bytes as a short human-readable size, e.g. "12.3M".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// usageBar renders a fixed-width bar showing size's percentage of the
+// parent directory's total (the sum of every entry in results), giving a
+// quick visual read of where the space went.
+func usageBar(size int64, results map[string]scanner.Result) string {
+	const width = 10
+	var total int64
+	for _, r := range results {
+		total += r.Size
+	}
+	filled := 0
+	if total > 0 {
+		filled = int(float64(size) / float64(total) * width)
+	}
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(" ", width-filled) + "]"
+}
+
+// Sort files by different criteria. usage, when non-nil, is the recursive
+// scan result map from disk-usage mode, and is consulted by the "size" sort
+// so directories rank by their whole-subtree total rather than just their
+// own directory-entry size. dirFirst controls whether directories sort
+// ahead of files for the "name"/"type" orderings.
+func sortFiles(files []os.DirEntry, sortBy string, usage map[string]scanner.Result, dirFirst bool) []os.DirEntry {
+	byName := func(i, j int) bool {
+		if dirFirst && files[i].IsDir() != files[j].IsDir() {
+			return files[i].IsDir()
+		}
+		return files[i].Name() < files[j].Name()
+	}
+
+	switch sortBy {
+	case "size":
+		sort.SliceStable(files, func(i, j int) bool {
+			return sizeOf(files[i], usage) > sizeOf(files[j], usage)
+		})
+	case "time":
+		sort.SliceStable(files, func(i, j int) bool {
+			infoI, _ := files[i].Info()
+			infoJ, _ := files[j].Info()
+			if infoI == nil || infoJ == nil {
+				return false
+			}
+			return infoI.ModTime().After(infoJ.ModTime())
+		})
+	case "type", "name":
+		sort.SliceStable(files, byName)
+	}
+	return files
+}
+
+// Filter files based on search input and the hidden-file setting.
+func filterFiles(files []os.DirEntry, searchInput string, showHidden bool) []os.DirEntry {
 	var filtered []os.DirEntry
 	for _, file := range files {
-		if strings.Contains(strings.ToLower(file.Name()), strings.ToLower(searchInput)) {
-			filtered = append(filtered, file)
+		if !showHidden && strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		if searchInput != "" && !strings.Contains(strings.ToLower(file.Name()), strings.ToLower(searchInput)) {
+			continue
 		}
+		filtered = append(filtered, file)
 	}
 	return filtered
 }
@@ -205,36 +766,31 @@ func findCursorPosition(files []os.DirEntry, targetDir string) int {
 	return 0
 }
 
-// Helper function to update scroll position
+// updateScroll keeps the cursor visible within the nav/usage-mode list.
+// Plain browsing doesn't need this: the filepicker does its own scrolling.
 func (m *model) updateScroll() {
 	if len(m.files) == 0 {
 		m.offset = 0
 		return
 	}
 
-	// Calculate how many items can fit in the visible area
-	// Reserve space for title, directory, and input (about 6 lines)
 	visibleItems := m.height - 6
 	if visibleItems < 1 {
 		visibleItems = 1
 	}
 
-	// If cursor is above visible area, scroll up
 	if m.cursor < m.offset {
 		m.offset = m.cursor
 	}
 
-	// If cursor is below visible area, scroll down
 	if m.cursor >= m.offset+visibleItems {
 		m.offset = m.cursor - visibleItems + 1
 	}
 
-	// Ensure offset doesn't go negative
 	if m.offset < 0 {
 		m.offset = 0
 	}
 
-	// Ensure offset doesn't exceed file count
 	if m.offset >= len(m.files) {
 		m.offset = len(m.files) - 1
 		if m.offset < 0 {
@@ -243,9 +799,81 @@ func (m *model) updateScroll() {
 	}
 }
 
+// browseFiles returns the current directory's entries in the same
+// name-sorted, hidden-filtered order the filepicker itself renders in
+// plain-browsing mode. This is deliberately independent of m.sortBy, which
+// nav mode's sort keys may have left pointed at size/time, and of
+// m.dirFirst — the filepicker has no such settings and always lists
+// directories first, then by name, so this must too or the cursor and the
+// highlighted row drift apart.
+func (m model) browseFiles() []os.DirEntry {
+	files := append([]os.DirEntry(nil), m.files...)
+	files = sortFiles(files, "name", nil, true)
+	return filterFiles(files, "", m.showHidden)
+}
+
+// highlightedPath returns the full path of the file currently under the
+// cursor, in whichever mode is active. m.cursor tracks the highlighted row
+// in every mode, including plain browsing, where the filepicker owns the
+// actual key handling but we shadow its cursor ourselves so the preview
+// pane has something to key off of: the filepicker only exposes a path
+// once a file is explicitly selected, which this app's Select binding
+// never triggers.
+func (m model) highlightedPath() (string, bool) {
+	switch {
+	case m.usageMode:
+		if len(m.files) == 0 || m.cursor >= len(m.files) {
+			return "", false
+		}
+		return filepath.Join(m.currentDir, m.files[m.cursor].Name()), true
+	case m.navMode:
+		if len(m.filteredFiles) == 0 || m.cursor >= len(m.filteredFiles) {
+			return "", false
+		}
+		return filepath.Join(m.currentDir, m.filteredFiles[m.cursor].Name()), true
+	default:
+		files := m.browseFiles()
+		if len(files) == 0 || m.cursor >= len(files) {
+			return "", false
+		}
+		return filepath.Join(m.currentDir, files[m.cursor].Name()), true
+	}
+}
+
+// refreshPreview marks the preview pane as loading and returns a command to
+// (re)render it for the currently highlighted file, or nil if the pane is
+// closed or nothing is highlighted.
+func (m *model) refreshPreview() tea.Cmd {
+	if !m.previewMode {
+		return nil
+	}
+	path, ok := m.highlightedPath()
+	if !ok {
+		return nil
+	}
+	m.previewLoading = true
+	return loadPreview(path, m.cfg.PreviewCmd, m.previewCache)
+}
+
+// startScan begins a fresh disk-usage scan of dir. If a previous scan is
+// still in flight, its done channel is closed first so it can unblock and
+// exit instead of leaking, rather than being left to write progress no one
+// will ever read again.
+func (m *model) startScan(dir string) tea.Cmd {
+	if m.scanning && m.scanDone != nil {
+		close(m.scanDone)
+	}
+	m.scanning = true
+	m.scannedCount = 0
+	m.scanResults = nil
+	m.scanProgress = make(chan scanner.Progress)
+	m.scanDone = make(chan struct{})
+	return tea.Batch(scanDir(dir, m.scanCache, m.scanProgress, m.scanDone), waitForScanProgress(m.scanProgress))
+}
+
 // Init function
 func (m model) Init() tea.Cmd {
-	return nil
+	return tea.Batch(m.picker.Init(), textinput.Blink)
 }
 
 // Update function
@@ -253,121 +881,329 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.height = msg.Height
+		m.width = msg.Width
+		m.picker.Height = msg.Height - 8
+		outputHeight := m.height / 3
+		if outputHeight < 3 {
+			outputHeight = 3
+		}
+		m.output.Width = m.width
+		m.output.Height = outputHeight
 		m.updateScroll()
 		return m, nil
 
 	case tea.KeyMsg:
 		if m.navMode {
-			// Navigation mode key handling
+			// Navigation (search) mode key handling
 			switch msg.String() {
 			case "esc":
 				m.navMode = false
-				m.searchInput = ""
+				m.searchField.SetValue("")
+				m.searchField.Blur()
+				m.commandInput.Focus()
 				return m, nil
-			case "backspace":
-				if len(m.searchInput) > 0 {
-					m.searchInput = m.searchInput[:len(m.searchInput)-1]
-					m.filteredFiles = filterFiles(m.files, m.searchInput)
-					m.cursor = 0
-					m.updateScroll()
-				}
 			case "up", "k":
 				if m.cursor > 0 {
 					m.cursor--
 					m.updateScroll()
 				}
+				return m, m.refreshPreview()
 			case "down", "j":
 				if m.cursor < len(m.filteredFiles)-1 {
 					m.cursor++
 					m.updateScroll()
 				}
+				return m, m.refreshPreview()
 			case "enter":
 				if len(m.filteredFiles) > 0 && m.cursor < len(m.filteredFiles) {
 					selected := m.filteredFiles[m.cursor]
 					if selected.IsDir() {
 						newDir := filepath.Join(m.currentDir, selected.Name())
 						m.lastDir = filepath.Base(m.currentDir)
-						m.searchInput = "" // Clear search box
-						m.filteredFiles = m.files // Reset filtered files
+						m.searchField.SetValue("")
+						m.filteredFiles = m.files
 						return m, changeDir(newDir)
 					}
 				}
-			case "1":
+			case m.cfg.Keymap.SortName:
 				m.sortBy = "name"
-				m.files = sortFiles(m.files, m.sortBy)
-				m.filteredFiles = filterFiles(m.files, m.searchInput)
+				m.files = sortFiles(m.files, m.sortBy, m.scanResults, m.dirFirst)
+				m.filteredFiles = filterFiles(m.files, m.searchField.Value(), m.showHidden)
 				m.cursor = 0
 				m.updateScroll()
-			case "2":
+			case m.cfg.Keymap.SortSize:
 				m.sortBy = "size"
-				m.files = sortFiles(m.files, m.sortBy)
-				m.filteredFiles = filterFiles(m.files, m.searchInput)
+				m.files = sortFiles(m.files, m.sortBy, m.scanResults, m.dirFirst)
+				m.filteredFiles = filterFiles(m.files, m.searchField.Value(), m.showHidden)
 				m.cursor = 0
 				m.updateScroll()
-			case "3":
+			case m.cfg.Keymap.SortTime:
 				m.sortBy = "time"
-				m.files = sortFiles(m.files, m.sortBy)
-				m.filteredFiles = filterFiles(m.files, m.searchInput)
+				m.files = sortFiles(m.files, m.sortBy, m.scanResults, m.dirFirst)
+				m.filteredFiles = filterFiles(m.files, m.searchField.Value(), m.showHidden)
 				m.cursor = 0
 				m.updateScroll()
-			case "4":
+			case m.cfg.Keymap.SortType:
 				m.sortBy = "type"
-				m.files = sortFiles(m.files, m.sortBy)
-				m.filteredFiles = filterFiles(m.files, m.searchInput)
+				m.files = sortFiles(m.files, m.sortBy, m.scanResults, m.dirFirst)
+				m.filteredFiles = filterFiles(m.files, m.searchField.Value(), m.showHidden)
 				m.cursor = 0
 				m.updateScroll()
+			case m.cfg.Keymap.Select:
+				if len(m.filteredFiles) > 0 && m.cursor < len(m.filteredFiles) {
+					path := filepath.Join(m.currentDir, m.filteredFiles[m.cursor].Name())
+					if m.selected[path] {
+						delete(m.selected, path)
+					} else {
+						m.selected[path] = true
+					}
+				}
+				return m, nil
+			case "*":
+				for _, file := range m.files {
+					path := filepath.Join(m.currentDir, file.Name())
+					if m.selected[path] {
+						delete(m.selected, path)
+					} else {
+						m.selected[path] = true
+					}
+				}
+				return m, nil
 			default:
-				if len(msg.String()) == 1 {
-					m.searchInput += msg.String()
-					m.filteredFiles = filterFiles(m.files, m.searchInput)
+				var cmd tea.Cmd
+				before := m.searchField.Value()
+				m.searchField, cmd = m.searchField.Update(msg)
+				if m.searchField.Value() != before {
+					m.filteredFiles = filterFiles(m.files, m.searchField.Value(), m.showHidden)
 					m.cursor = 0
 					m.updateScroll()
 				}
+				return m, cmd
+			}
+		} else if m.confirmDelete != "" {
+			// Waiting on a y/N confirmation for a pending delete
+			switch msg.String() {
+			case "y", "Y":
+				path := m.confirmDelete
+				m.confirmDelete = ""
+				return m, deleteEntry(path)
+			default:
+				m.confirmDelete = ""
+				m.message = "Delete cancelled"
+			}
+		} else if m.confirmBatch != nil {
+			// Waiting on a y/N confirmation for a batch cp!/mv!/rm!
+			switch msg.String() {
+			case "y", "Y":
+				op := m.confirmBatch
+				m.confirmBatch = nil
+				return m, runBatchOp(op, m.currentDir)
+			default:
+				m.confirmBatch = nil
+				m.message = "Cancelled"
 			}
 		} else {
 			// Normal mode key handling
 			switch msg.String() {
 			case "ctrl+c":
+				if m.running != nil {
+					m.running.cancel()
+					m.message = "Cancelling " + m.running.label + "..."
+					return m, nil
+				}
 				return m, tea.Quit
 			case "esc":
 				m.navMode = true
-				m.searchInput = ""
-				m.filteredFiles = m.files
+				m.commandInput.Blur()
+				m.searchField.Focus()
+				m.searchField.SetValue("")
+				m.filteredFiles = filterFiles(m.files, "", m.showHidden)
 				return m, nil
-			case "up":
-				if m.cursor > 0 {
-					m.cursor--
-					m.updateScroll()
+			case m.cfg.Keymap.ToggleHidden:
+				// Only claim this key on an empty prompt; otherwise it's a
+				// "." the user is typing into a command (e.g. "./script").
+				if m.commandInput.Value() != "" {
+					var cmd tea.Cmd
+					m.commandInput, cmd = m.commandInput.Update(msg)
+					return m, cmd
 				}
-			case "down":
-				if m.cursor < len(m.files)-1 {
-					m.cursor++
-					m.updateScroll()
+				m.showHidden = !m.showHidden
+				m.cfg.ShowHidden = m.showHidden
+				m.picker.ShowHidden = m.showHidden
+				m.filteredFiles = filterFiles(m.files, m.searchField.Value(), m.showHidden)
+				m.cursor = 0
+				m.updateScroll()
+				return m, nil
+			case m.cfg.Keymap.NavHome:
+				// Only claim this key on an empty prompt; otherwise it's a
+				// "~" the user is typing into a command (e.g. "~/bin/foo").
+				if m.commandInput.Value() != "" {
+					var cmd tea.Cmd
+					m.commandInput, cmd = m.commandInput.Update(msg)
+					return m, cmd
+				}
+				home := os.Getenv("HOME")
+				if home == "" {
+					return m, nil
 				}
-			case "left":
-				// Go to parent directory
-				parentDir := filepath.Dir(m.currentDir)
 				m.lastDir = filepath.Base(m.currentDir)
-				return m, changeDir(parentDir)
-			case "right":
-				// Enter selected directory
+				return m, changeDir(home)
+			case m.cfg.Keymap.UsageMode:
+				// Only claim this key on an empty prompt; otherwise it's a
+				// letter the user is typing into a command (e.g. "du").
+				if m.commandInput.Value() != "" {
+					var cmd tea.Cmd
+					m.commandInput, cmd = m.commandInput.Update(msg)
+					return m, cmd
+				}
+				m.usageMode = !m.usageMode
+				if m.usageMode {
+					return m, m.startScan(m.currentDir)
+				}
+				return m, nil
+			case m.cfg.Keymap.Preview:
+				m.previewMode = !m.previewMode
+				if !m.previewMode {
+					m.previewResult = preview.Result{}
+					return m, nil
+				}
+				return m, m.refreshPreview()
+			case m.cfg.Keymap.Delete:
+				// Only claim this key in usage mode, where it's the
+				// documented delete shortcut; elsewhere it's a letter the
+				// user is typing into a command (e.g. "cd", "mkdir").
+				if !m.usageMode {
+					var cmd tea.Cmd
+					m.commandInput, cmd = m.commandInput.Update(msg)
+					return m, cmd
+				}
 				if len(m.files) > 0 && m.cursor < len(m.files) {
 					selected := m.files[m.cursor]
-					if selected.IsDir() {
-						newDir := filepath.Join(m.currentDir, selected.Name())
+					m.confirmDelete = filepath.Join(m.currentDir, selected.Name())
+					m.message = fmt.Sprintf("Delete %s? (y/N)", selected.Name())
+				}
+				return m, nil
+			case "ctrl+p", "ctrl+n":
+				// Up/Down are already claimed by file-cursor movement on
+				// an empty prompt in every mode, so history recall gets
+				// its own keys rather than fighting them for "up"/"down".
+				if msg.String() == "ctrl+p" {
+					m.browseHistory("up")
+				} else {
+					m.browseHistory("down")
+				}
+				return m, nil
+			case "up", "down", m.cfg.Keymap.NavOut, m.cfg.Keymap.NavIn:
+				if m.usageMode {
+					switch msg.String() {
+					case "up":
+						if m.cursor > 0 {
+							m.cursor--
+							m.updateScroll()
+						}
+						return m, m.refreshPreview()
+					case "down":
+						if m.cursor < len(m.files)-1 {
+							m.cursor++
+							m.updateScroll()
+						}
+						return m, m.refreshPreview()
+					case m.cfg.Keymap.NavOut:
+						parentDir := filepath.Dir(m.currentDir)
 						m.lastDir = filepath.Base(m.currentDir)
-						return m, changeDir(newDir)
+						return m, changeDir(parentDir)
+					case m.cfg.Keymap.NavIn:
+						if len(m.files) > 0 && m.cursor < len(m.files) {
+							selected := m.files[m.cursor]
+							if selected.IsDir() {
+								newDir := filepath.Join(m.currentDir, selected.Name())
+								m.lastDir = filepath.Base(m.currentDir)
+								return m, changeDir(newDir)
+							}
+						}
+					}
+					return m, nil
+				}
+
+				// Plain browsing: the filepicker owns cursor movement and
+				// directory traversal, we just notice when it moved us and
+				// shadow its cursor in m.cursor for the preview pane.
+				var cmd tea.Cmd
+				m.picker, cmd = m.picker.Update(msg)
+				if m.picker.CurrentDirectory != m.currentDir {
+					m.lastDir = filepath.Base(m.currentDir)
+					m.currentDir = m.picker.CurrentDirectory
+					sortBy, dirFirst, showHidden := resolveLocal(m.cfg, m.currentDir)
+					m.sortBy, m.dirFirst, m.showHidden = sortBy, dirFirst, showHidden
+					m.picker.ShowHidden = showHidden
+					files, _ := os.ReadDir(m.currentDir)
+					m.files = sortFiles(files, m.sortBy, nil, m.dirFirst)
+					m.filteredFiles = filterFiles(m.files, "", m.showHidden)
+					m.cursor = 0
+				} else {
+					switch msg.String() {
+					case "up":
+						if m.cursor > 0 {
+							m.cursor--
+						}
+					case "down":
+						if m.cursor < len(m.browseFiles())-1 {
+							m.cursor++
+						}
 					}
 				}
+				return m, tea.Batch(cmd, m.refreshPreview())
 			case "enter":
 				// Execute command
-				cmd := strings.TrimSpace(m.input)
-				m.input = "" // Clear input immediately
-				
+				cmd := strings.TrimSpace(m.commandInput.Value())
+				m.commandInput.SetValue("")
+
 				if cmd == "" {
 					return m, nil
 				}
-				
+
+				if cmd == ":mkconfig" {
+					if err := config.Save(m.cfg); err != nil {
+						m.message = fmt.Sprintf("Error saving config: %v", err)
+					} else {
+						m.message = "Saved config to " + config.Path()
+					}
+					return m, nil
+				}
+
+				if strings.HasPrefix(cmd, ":set ") {
+					m.applySet(strings.TrimSpace(strings.TrimPrefix(cmd, ":set ")))
+					m.filteredFiles = filterFiles(m.files, "", m.showHidden)
+					return m, nil
+				}
+
+				// A leading "!" runs the rest of the line with the current
+				// selection substituted for "{}" (or appended if absent).
+				if strings.HasPrefix(cmd, "!") {
+					if len(m.selected) == 0 {
+						m.message = "No files selected"
+						return m, nil
+					}
+					output, err := executeOnSelection(strings.TrimSpace(cmd[1:]), m.selected, m.currentDir)
+					if err != nil {
+						m.message = fmt.Sprintf("Error: %v\n%s", err, output)
+					} else {
+						m.message = output
+					}
+					return m, changeDir(m.currentDir)
+				}
+
+				// A trailing "&" runs the command as a background job: it
+				// doesn't stream into the output pane, and its completion is
+				// just announced in the status bar. The original request
+				// described this as a leading "!", but that prefix was
+				// already claimed above for run-on-selection, so background
+				// jobs use the shell's own trailing "&" convention instead.
+				background := strings.HasSuffix(cmd, "&")
+				if background {
+					cmd = strings.TrimSpace(strings.TrimSuffix(cmd, "&"))
+				}
+
 				// Handle commands
 				parts := strings.Fields(cmd)
 				if len(parts) == 0 {
@@ -379,6 +1215,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// Special handling for built-in commands
 				switch command {
+				case "cp!", "mv!", "rm!":
+					if len(m.selected) == 0 {
+						m.message = "No files selected"
+						return m, nil
+					}
+					paths := make([]string, 0, len(m.selected))
+					for path := range m.selected {
+						paths = append(paths, path)
+					}
+					sort.Strings(paths)
+
+					dest := ""
+					if len(args) > 0 {
+						dest = args[0]
+					}
+					m.confirmBatch = &batchOp{name: command, paths: paths, dest: dest}
+
+					verb := strings.TrimSuffix(command, "!")
+					if dest != "" {
+						m.message = fmt.Sprintf("%s %d selected item(s) to %s? (y/N)", verb, len(paths), dest)
+					} else {
+						m.message = fmt.Sprintf("%s %d selected item(s)? (y/N)", verb, len(paths))
+					}
+					return m, nil
+
 				case "cd":
 					newDir := ""
 					if len(args) > 0 {
@@ -400,43 +1261,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				case "clear":
 					m.message = ""
+					m.outputBuf = nil
+					m.output.SetContent("")
 					return m, nil
 
 				case "quit", "exit":
 					return m, tea.Quit
 
 				default:
-					// Execute system command
-					output, err := executeCommand(command, args, m.currentDir)
-					if err != nil {
-						m.message = fmt.Sprintf("Error executing %s: %v\n%s", command, err, output)
-					} else {
-						if strings.TrimSpace(output) == "" {
-							m.message = fmt.Sprintf("Command '%s' executed successfully", command)
-						} else {
-							// Truncate long output
-							if len(output) > 500 {
-								output = output[:500] + "\n... (output truncated)"
-							}
-							m.message = output
-						}
-						
-						// Refresh directory listing for file operations
-						if command == "touch" || command == "mkdir" || command == "rm" || 
-						   command == "rmdir" || command == "cp" || command == "mv" {
-							return m, changeDir(m.currentDir)
-						}
+					m.appendHistory(cmd)
+
+					if background {
+						m.message = fmt.Sprintf("Started %s in background", cmd)
+						return m, runBackgroundJob(command, args, m.currentDir, cmd)
 					}
-					return m, nil
-				}
-			case "backspace":
-				if len(m.input) > 0 {
-					m.input = m.input[:len(m.input)-1]
+
+					m.outputBuf = nil
+					m.output.SetContent("")
+					m.message = ""
+					rc := runCommandAsync(command, args, m.currentDir, cmd)
+					m.running = rc
+					return m, waitForOutputLine(rc)
 				}
 			default:
-				if len(msg.String()) == 1 {
-					m.input += msg.String()
-				}
+				var cmd tea.Cmd
+				m.commandInput, cmd = m.commandInput.Update(msg)
+				return m, cmd
 			}
 		}
 
@@ -445,9 +1295,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.err = msg.err
 		} else {
 			m.currentDir = msg.dir
-			m.files = msg.files
-			m.filteredFiles = m.files
-			
+			sortBy, dirFirst, showHidden := resolveLocal(m.cfg, m.currentDir)
+			m.sortBy, m.dirFirst, m.showHidden = sortBy, dirFirst, showHidden
+			m.picker.ShowHidden = showHidden
+			m.files = sortFiles(msg.files, m.sortBy, m.scanResults, m.dirFirst)
+			m.filteredFiles = filterFiles(m.files, "", m.showHidden)
+			m.picker.CurrentDirectory = msg.dir
+
 			// Position cursor at the directory we came from
 			if m.lastDir != "" {
 				m.cursor = findCursorPosition(m.files, m.lastDir)
@@ -455,27 +1309,163 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			} else {
 				m.cursor = 0
 			}
-			
+
 			m.offset = 0
 			m.err = nil
 			m.updateScroll()
+
+			if m.usageMode {
+				return m, tea.Batch(m.picker.Init(), m.startScan(m.currentDir), m.refreshPreview())
+			}
+			return m, tea.Batch(m.picker.Init(), m.refreshPreview())
+		}
+
+	case scanProgressMsg:
+		m.scannedCount = msg.Scanned
+		return m, waitForScanProgress(m.scanProgress)
+
+	case scanResultMsg:
+		if msg.done != m.scanDone {
+			// A result from a scan we've since abandoned; ignore it.
+			return m, nil
+		}
+		m.scanning = false
+		if msg.dir == m.currentDir && msg.err == nil {
+			m.scanResults = msg.results
+			m.files = sortFiles(m.files, m.sortBy, m.scanResults, m.dirFirst)
+			m.filteredFiles = filterFiles(m.files, "", m.showHidden)
 		}
+
+	case batchOpDoneMsg:
+		verb := strings.TrimSuffix(msg.op, "!")
+		if msg.err != nil {
+			m.message = fmt.Sprintf("Error during %s: %v\n%s", verb, msg.err, msg.output)
+		} else {
+			m.message = fmt.Sprintf("%s complete", verb)
+		}
+		if msg.op == "rm!" || msg.op == "mv!" {
+			m.selected = make(map[string]bool)
+		}
+		return m, changeDir(m.currentDir)
+
+	case outputLineMsg:
+		if !msg.ok {
+			if m.running != nil {
+				return m, waitForCommandDone(m.running)
+			}
+			return m, nil
+		}
+		m.outputBuf = append(m.outputBuf, msg.line)
+		m.output.SetContent(strings.Join(m.outputBuf, "\n"))
+		m.output.GotoBottom()
+		return m, waitForOutputLine(m.running)
+
+	case commandDoneMsg:
+		finished := m.running == msg.cmd
+		if finished {
+			m.running = nil
+		}
+		if msg.err != nil {
+			m.message = fmt.Sprintf("%s exited: %v", msg.cmd.label, msg.err)
+		} else {
+			m.message = fmt.Sprintf("%s finished", msg.cmd.label)
+		}
+		if finished && mutatesFilesystem(msg.cmd.name) {
+			return m, changeDir(m.currentDir)
+		}
+		return m, nil
+
+	case bgJobDoneMsg:
+		if msg.err != nil {
+			m.message = fmt.Sprintf("background job %q failed: %v\n%s", msg.label, msg.err, msg.output)
+		} else {
+			m.message = fmt.Sprintf("background job %q finished", msg.label)
+		}
+		return m, changeDir(m.currentDir)
+
+	case previewResultMsg:
+		// Discard a render that finished after the cursor moved elsewhere.
+		if path, ok := m.highlightedPath(); ok && path == msg.path {
+			m.previewResult = msg.result
+			m.previewLoading = false
+		}
+		return m, nil
+
+	default:
+		// Anything we don't handle ourselves (notably the filepicker's own
+		// internal messages, e.g. the readDirMsg its Init/Update commands
+		// produce) still needs to reach it, or its listing never loads.
+		var cmd tea.Cmd
+		m.picker, cmd = m.picker.Update(msg)
+		return m, cmd
 	}
 
 	return m, nil
 }
 
-// View function
-func (m model) View() string {
-	if m.err != nil {
-		return fmt.Sprintf("Error: %v\n\nPress any key to continue...", m.err)
+// markSelections prepends a marked-style "✓" to each line of the
+// filepicker's rendered view whose file is in m.selected. The filepicker
+// has no hook for per-row styling, so this matches rendered lines against
+// file names instead, using containsName to avoid e.g. "notes" matching a
+// rendered "notes.bak" row.
+func (m model) markSelections(view string) string {
+	if len(m.selected) == 0 {
+		return view
 	}
+	lines := strings.Split(view, "\n")
+	for i, line := range lines {
+		for _, file := range m.files {
+			if !m.selected[filepath.Join(m.currentDir, file.Name())] {
+				continue
+			}
+			if containsName(line, file.Name()) {
+				lines[i] = markedStyle.Render("✓") + " " + line
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
 
+// containsName reports whether line contains name as a whole filename,
+// not merely as a substring of a longer one.
+func containsName(line, name string) bool {
+	for start := 0; ; {
+		idx := strings.Index(line[start:], name)
+		if idx == -1 {
+			return false
+		}
+		idx += start
+		end := idx + len(name)
+		before := idx == 0 || !isNameByte(line[idx-1])
+		after := end == len(line) || !isNameByte(line[end])
+		if before && after {
+			return true
+		}
+		start = idx + 1
+	}
+}
+
+func isNameByte(b byte) bool {
+	return b == '.' || b == '_' || b == '-' ||
+		(b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// renderBrowser renders the title, current directory and file listing: the
+// part of the view that shrinks to make room for the preview pane when it's
+// open.
+func (m model) renderBrowser() string {
 	var s strings.Builder
 
 	// Title
 	if m.navMode {
 		s.WriteString(navModeStyle.Render("Navigation Mode"))
+	} else if m.usageMode {
+		title := "Disk Usage"
+		if m.scanning {
+			title = fmt.Sprintf("Disk Usage (scanning... %d entries)", m.scannedCount)
+		}
+		s.WriteString(titleStyle.Render(title))
 	} else {
 		s.WriteString(titleStyle.Render("File Manager"))
 	}
@@ -484,78 +1474,177 @@ func (m model) View() string {
 	// Current directory
 	s.WriteString(fmt.Sprintf("Directory: %s\n\n", m.currentDir))
 
-	// Navigation mode info
-	if m.navMode {
-		s.WriteString(searchStyle.Render("Search: " + m.searchInput + "_"))
-		s.WriteString("\n")
-		s.WriteString(fmt.Sprintf("Sort: %s (1=name, 2=size, 3=time, 4=type)\n", m.sortBy))
+	if !m.navMode && !m.usageMode {
+		// Plain browsing: let the filepicker render its own list, styles
+		// and scroll indicator, marking selected rows ourselves since it
+		// has no hook for that.
+		s.WriteString(m.markSelections(m.picker.View()))
 		s.WriteString("\n")
-	}
-
-	// File list
-	filesToShow := m.files
-	if m.navMode {
-		filesToShow = m.filteredFiles
-	}
-
-	if len(filesToShow) == 0 {
-		if m.navMode && m.searchInput != "" {
-			s.WriteString("No files match your search\n")
-		} else {
-			s.WriteString("No files in this directory\n")
-		}
 	} else {
-		// Calculate visible range
-		visibleItems := m.height - 8
+		// Navigation mode info
 		if m.navMode {
-			visibleItems = m.height - 10
-		}
-		if visibleItems < 1 {
-			visibleItems = 1
+			s.WriteString(searchStyle.Render(m.searchField.View()))
+			s.WriteString("\n")
+			s.WriteString(fmt.Sprintf("Sort: %s (%s=name, %s=size, %s=time, %s=type)\n", m.sortBy,
+				m.cfg.Keymap.SortName, m.cfg.Keymap.SortSize, m.cfg.Keymap.SortTime, m.cfg.Keymap.SortType))
+			s.WriteString("\n")
 		}
-		
-		start := m.offset
-		end := start + visibleItems
-		if end > len(filesToShow) {
-			end = len(filesToShow)
+
+		// File list
+		filesToShow := m.files
+		if m.navMode {
+			filesToShow = m.filteredFiles
 		}
 
-		// Show only visible files
-		for i := start; i < end; i++ {
-			file := filesToShow[i]
-			cursor := " "
-			if m.cursor == i {
-				cursor = ">"
+		if len(filesToShow) == 0 {
+			if m.navMode && m.searchField.Value() != "" {
+				s.WriteString("No files match your search\n")
+			} else {
+				s.WriteString("No files in this directory\n")
 			}
-
-			style := itemStyle
-			if m.cursor == i {
-				style = selectedStyle
+		} else {
+			// Calculate visible range
+			visibleItems := m.height - 8
+			if m.navMode {
+				visibleItems = m.height - 10
+			}
+			if visibleItems < 1 {
+				visibleItems = 1
 			}
 
-			icon := "📄"
-			if file.IsDir() {
-				icon = "📁"
+			start := m.offset
+			end := start + visibleItems
+			if end > len(filesToShow) {
+				end = len(filesToShow)
 			}
 
-			line := fmt.Sprintf("%s %s %s", cursor, icon, file.Name())
-			s.WriteString(style.Render(line))
-			s.WriteString("\n")
-		}
+			// Show only visible files
+			for i := start; i < end; i++ {
+				file := filesToShow[i]
+				cursor := " "
+				if m.cursor == i {
+					cursor = ">"
+				}
+
+				marked := m.selected[filepath.Join(m.currentDir, file.Name())]
+				mark := " "
+				if marked {
+					mark = "✓"
+				}
+
+				style := itemStyle
+				switch {
+				case m.cursor == i:
+					style = selectedStyle
+				case marked:
+					style = markedStyle
+				}
+
+				icon := "📄"
+				if file.IsDir() {
+					icon = "📁"
+				}
 
-		// Show scroll indicator if there are more files
-		if len(filesToShow) > visibleItems {
-			if m.offset > 0 {
-				s.WriteString("... ↑ more files above ...\n")
+				var line string
+				if m.usageMode && m.scanResults != nil {
+					line = fmt.Sprintf("%s%s %s %s", cursor, mark, icon, file.Name())
+					if r, ok := m.scanResults[file.Name()]; ok {
+						line = fmt.Sprintf("%s%s %s %8s %s %s", cursor, mark, usageBar(r.Size, m.scanResults), formatSize(r.Size), icon, file.Name())
+						if r.IsDir {
+							line += fmt.Sprintf(" (%d items)", r.ItemCount)
+						}
+					}
+				} else {
+					line = fmt.Sprintf("%s%s %s %s", cursor, mark, icon, file.Name())
+				}
+				s.WriteString(style.Render(line))
+				s.WriteString("\n")
 			}
-			if end < len(filesToShow) {
-				s.WriteString("... ↓ more files below ...\n")
+
+			// Show scroll indicator if there are more files
+			if len(filesToShow) > visibleItems {
+				if m.offset > 0 {
+					s.WriteString("... ↑ more files above ...\n")
+				}
+				if end < len(filesToShow) {
+					s.WriteString("... ↓ more files below ...\n")
+				}
 			}
 		}
 	}
 
+	return s.String()
+}
+
+// previewWidth returns the column width given to the preview pane: roughly
+// a third of the terminal, with a floor so it stays readable in narrow
+// windows.
+func previewWidth(totalWidth int) int {
+	w := totalWidth / 3
+	if w < 24 {
+		w = 24
+	}
+	return w
+}
+
+var previewBoxStyle = lipgloss.NewStyle().
+	Padding(0, 1).
+	Border(lipgloss.NormalBorder())
+
+// renderPreviewPane renders the right-hand preview box for the file
+// currently under the cursor.
+func (m model) renderPreviewPane() string {
+	height := m.height - 4
+	if height < 3 {
+		height = 3
+	}
+
+	body := "No preview"
+	switch {
+	case m.previewLoading:
+		body = "Loading preview..."
+	case m.previewResult.Err != nil:
+		body = fmt.Sprintf("preview error: %v", m.previewResult.Err)
+	case m.previewResult.Content != "":
+		body = m.previewResult.Content
+	}
+
+	return previewBoxStyle.Width(previewWidth(m.width)).Height(height).MaxHeight(height).Render(body)
+}
+
+// View function
+func (m model) View() string {
+	if m.err != nil {
+		return fmt.Sprintf("Error: %v\n\nPress any key to continue...", m.err)
+	}
+
+	var s strings.Builder
+
+	browser := m.renderBrowser()
+	if m.previewMode {
+		browser = lipgloss.JoinHorizontal(lipgloss.Top, browser, m.renderPreviewPane())
+	}
+	s.WriteString(browser)
 	s.WriteString("\n")
 
+	// Command output pane: shown while a foreground command is streaming
+	// output, and left in place afterward until the next command clears it.
+	if len(m.outputBuf) > 0 || m.running != nil {
+		title := "Output"
+		if m.running != nil {
+			title = "Output (running, Ctrl-C to cancel)"
+		}
+		s.WriteString(titleStyle.Render(title))
+		s.WriteString("\n")
+		s.WriteString(m.output.View())
+		s.WriteString("\n\n")
+	}
+
+	// Selection status
+	if n, total := selectionStats(m.selected); n > 0 {
+		s.WriteString(fmt.Sprintf("%d selected, %s\n", n, formatSize(total)))
+	}
+
 	// Show message if any
 	if m.message != "" {
 		s.WriteString(messageStyle.Render(m.message))
@@ -564,11 +1653,20 @@ func (m model) View() string {
 
 	// Command input or navigation mode info
 	if m.navMode {
-		s.WriteString("Navigation Mode: ESC to exit, type to search, 1-4 to sort, ↑↓ to navigate\n")
+		selectKey := m.cfg.Keymap.Select
+		if selectKey == " " {
+			selectKey = "space"
+		}
+		s.WriteString(fmt.Sprintf("Navigation Mode: ESC to exit, type to search, %s/%s/%s/%s to sort, %s to mark, * to invert, ↑↓ to navigate\n",
+			m.cfg.Keymap.SortName, m.cfg.Keymap.SortSize, m.cfg.Keymap.SortTime, m.cfg.Keymap.SortType, selectKey))
 	} else {
-		s.WriteString(inputStyle.Render("$ " + m.input + "_"))
+		s.WriteString(inputStyle.Render(m.commandInput.View()))
 		s.WriteString("\n")
-		s.WriteString("Built-in: cd, ls, pwd, clear, quit | System commands: touch, mkdir, rm, cp, mv, cat, grep, find, etc. | Navigation: ↑↓, ←→, Enter | ESC: Nav Mode\n")
+		s.WriteString(fmt.Sprintf("Built-in: cd, ls, pwd, clear, quit, :set, :mkconfig, cp!/mv!/rm! (on selection), !cmd {} (on selection), cmd & (background) | System commands stream into the output pane below, Ctrl-C cancels | Navigation: ↑↓, %s%s, Enter, %s: home | %s: Usage Mode | %s: toggle hidden | %s: toggle preview | ESC: Nav Mode | Ctrl-P/Ctrl-N: command history\n",
+			m.cfg.Keymap.NavOut, m.cfg.Keymap.NavIn, m.cfg.Keymap.NavHome, m.cfg.Keymap.UsageMode, m.cfg.Keymap.ToggleHidden, m.cfg.Keymap.Preview))
+		if m.usageMode {
+			s.WriteString(fmt.Sprintf("Usage Mode: %s to delete highlighted entry\n", m.cfg.Keymap.Delete))
+		}
 	}
 
 	return s.String()
@@ -580,4 +1678,4 @@ func main() {
 		fmt.Printf("Error: %v", err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,301 @@
+// Package preview renders a short summary of the file under the cursor for
+// filerover's preview pane: syntax-highlighted text via chroma, images
+// through a configurable external viewer, directories as a recursive tree
+// summary, and archives via their native listing tools. Like package
+// scanner, it is decoupled from bubbletea — callers get a plain Result and
+// decide for themselves how to turn it into a tea.Msg.
+package preview
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const (
+	// maxTextBytes caps how much of a text file is read for highlighting.
+	maxTextBytes = 64 * 1024
+	// maxFallbackBytes is the size of the "head"-style fallback preview.
+	maxFallbackBytes = 4 * 1024
+	// maxTreeEntries caps how many lines a directory preview can produce, so
+	// a huge subtree doesn't hang the pane.
+	maxTreeEntries = 500
+)
+
+var imageExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".bmp": true, ".webp": true,
+}
+
+var tarSuffixes = []string{".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tar.xz"}
+
+// Result is a rendered preview ready to display in the preview pane.
+type Result struct {
+	Kind    string // "text", "image", "dir", "archive", "fallback"
+	Content string
+	Err     error
+}
+
+type cacheKey struct {
+	path  string
+	mtime int64
+	size  int64
+}
+
+// Cache remembers the rendered preview for a (path, mtime, size) triple so
+// revisiting an unchanged file doesn't re-run an external viewer or
+// re-highlight its contents. When adding a new entry would take it past its
+// capacity, the least recently used entry is evicted first.
+type Cache struct {
+	mu      sync.Mutex
+	cap     int
+	order   []cacheKey // least recently used first
+	entries map[cacheKey]Result
+}
+
+// NewCache returns an empty preview cache holding at most cap entries. A cap
+// of zero or less leaves the cache unbounded.
+func NewCache(cap int) *Cache {
+	return &Cache{cap: cap, entries: make(map[cacheKey]Result)}
+}
+
+func (c *Cache) get(key cacheKey) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[key]
+	if ok {
+		c.touch(key)
+	}
+	return r, ok
+}
+
+func (c *Cache) touch(key cacheKey) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, key)
+}
+
+func (c *Cache) put(key cacheKey, r Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists && c.cap > 0 && len(c.entries) >= c.cap {
+		evict := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, evict)
+	}
+	c.entries[key] = r
+	c.touch(key)
+}
+
+// Render returns the preview for path, consulting cache first. imageCmd
+// names the external viewer ("chafa", "viu", ...) used to render images.
+func Render(path, imageCmd string, cache *Cache) Result {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Result{Kind: "fallback", Err: err}
+	}
+
+	key := cacheKey{path: path, mtime: info.ModTime().UnixNano(), size: info.Size()}
+	if cache != nil {
+		if r, ok := cache.get(key); ok {
+			return r
+		}
+	}
+
+	r := render(path, info, imageCmd)
+	if cache != nil {
+		cache.put(key, r)
+	}
+	return r
+}
+
+// render picks a handler by path/mode and falls back to a plain head when
+// nothing more specific matches.
+func render(path string, info os.FileInfo, imageCmd string) Result {
+	switch {
+	case info.IsDir():
+		return renderDir(path)
+	case isTar(path):
+		return renderArchive("tar", []string{"-tf", path})
+	case strings.EqualFold(filepath.Ext(path), ".zip"):
+		return renderArchive("unzip", []string{"-l", path})
+	case imageExts[strings.ToLower(filepath.Ext(path))]:
+		return renderImage(path, imageCmd)
+	default:
+		return renderText(path)
+	}
+}
+
+func isTar(path string) bool {
+	lower := strings.ToLower(path)
+	for _, suffix := range tarSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderText syntax-highlights path's first maxTextBytes for an ANSI
+// terminal, degrading to a plain head when the file looks binary or chroma
+// can't make sense of it.
+func renderText(path string) Result {
+	data, err := readHead(path, maxTextBytes)
+	if err != nil {
+		return Result{Kind: "fallback", Err: err}
+	}
+	if looksBinary(data) {
+		return renderFallback(path)
+	}
+
+	highlighted, err := highlight(path, data)
+	if err != nil {
+		return renderFallback(path)
+	}
+	return Result{Kind: "text", Content: highlighted}
+}
+
+// highlight renders source as ANSI-highlighted text, picking a lexer by
+// path's name/extension and falling back to chroma's content-based guess.
+func highlight(path string, source []byte) (string, error) {
+	lexer := lexers.Match(path)
+	if lexer == nil {
+		lexer = lexers.Analyse(string(source))
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	style := styles.Get("monokai")
+	if style == nil {
+		style = styles.Fallback
+	}
+	formatter := formatters.Get("terminal256")
+	if formatter == nil {
+		formatter = formatters.Fallback
+	}
+
+	iterator, err := lexer.Tokenise(nil, string(source))
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := formatter.Format(&out, style, iterator); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// renderFallback degrades to a plain "head -c 4KB"-style preview for files
+// no other handler matched.
+func renderFallback(path string) Result {
+	data, err := readHead(path, maxFallbackBytes)
+	if err != nil {
+		return Result{Kind: "fallback", Err: err}
+	}
+	return Result{Kind: "fallback", Content: string(data)}
+}
+
+// readHead reads up to n bytes from the start of path.
+func readHead(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, n)
+	read, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// looksBinary reports whether data contains a NUL byte, the same heuristic
+// grep and lf use to decide a file isn't text.
+func looksBinary(data []byte) bool {
+	for _, b := range data {
+		if b == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// renderImage pipes path through the configured external viewer.
+func renderImage(path, imageCmd string) Result {
+	if imageCmd == "" {
+		return renderFallback(path)
+	}
+	out, err := exec.Command(imageCmd, path).CombinedOutput()
+	if err != nil {
+		return Result{Kind: "image", Content: string(out), Err: err}
+	}
+	return Result{Kind: "image", Content: string(out)}
+}
+
+// renderArchive lists an archive's contents via its native tool.
+func renderArchive(command string, args []string) Result {
+	out, err := exec.Command(command, args...).CombinedOutput()
+	if err != nil {
+		return Result{Kind: "archive", Content: string(out), Err: err}
+	}
+	return Result{Kind: "archive", Content: string(out)}
+}
+
+// renderDir summarizes a directory as a depth-first tree, capped at
+// maxTreeEntries lines.
+func renderDir(path string) Result {
+	var lines []string
+	err := walkTree(path, "", &lines)
+	content := strings.Join(lines, "\n")
+	if len(lines) >= maxTreeEntries {
+		content += "\n... truncated ..."
+	}
+	return Result{Kind: "dir", Content: content, Err: err}
+}
+
+func walkTree(dir, prefix string, lines *[]string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for i, entry := range entries {
+		if len(*lines) >= maxTreeEntries {
+			return nil
+		}
+		connector, childPrefix := "├── ", prefix+"│   "
+		if i == len(entries)-1 {
+			connector, childPrefix = "└── ", prefix+"    "
+		}
+
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		*lines = append(*lines, prefix+connector+name)
+
+		if entry.IsDir() {
+			// A permission error on a subtree shouldn't blank the rest of
+			// the preview; just stop descending into it.
+			_ = walkTree(filepath.Join(dir, entry.Name()), childPrefix, lines)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package scanner implements the recursive directory-size walker used by
+// filerover's disk-usage mode. It is deliberately decoupled from bubbletea:
+// callers get plain Go values and a channel of progress events, and decide
+// for themselves how to turn those into tea.Msg values.
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// Result is the computed size of a single top-level entry within a scanned
+// directory.
+type Result struct {
+	Size      int64
+	ItemCount int
+	IsDir     bool
+}
+
+// Progress reports incremental scan progress for a long-running walk so the
+// UI can stay responsive while large trees are summed.
+type Progress struct {
+	Dir     string
+	Scanned int
+	Current string
+}
+
+type cacheKey struct {
+	inode uint64
+	mtime int64
+}
+
+// Cache remembers the computed size of a directory keyed by inode and mtime
+// so that revisiting an unchanged subtree doesn't force a rescan.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]Result
+}
+
+// NewCache returns an empty scan cache.
+func NewCache() *Cache {
+	return &Cache{entries: make(map[cacheKey]Result)}
+}
+
+func (c *Cache) lookup(key cacheKey) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[key]
+	return r, ok
+}
+
+func (c *Cache) store(key cacheKey, r Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = r
+}
+
+func statKey(info os.FileInfo) (cacheKey, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return cacheKey{}, false
+	}
+	return cacheKey{inode: stat.Ino, mtime: info.ModTime().UnixNano()}, true
+}
+
+// WalkChildren computes the recursive size of every direct child of dir and
+// reports one Progress on progress per entry visited (progress may be nil,
+// in which case no reporting happens). It does not close progress.
+//
+// done lets a caller abandon an in-flight walk, e.g. because the user
+// navigated elsewhere before it finished: once done is closed, a blocked
+// progress send is dropped instead of waiting forever for a reader that
+// may never come back, so the walk still runs to completion and returns
+// rather than leaking this goroutine.
+func WalkChildren(dir string, cache *Cache, progress chan<- Progress, done <-chan struct{}) (map[string]Result, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]Result, len(entries))
+	scanned := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		size, count, isDir, err := sum(path, cache)
+		if err != nil {
+			continue
+		}
+		results[entry.Name()] = Result{Size: size, ItemCount: count, IsDir: isDir}
+
+		scanned++
+		if progress != nil {
+			select {
+			case progress <- Progress{Dir: dir, Scanned: scanned, Current: path}:
+			case <-done:
+			}
+		}
+	}
+	return results, nil
+}
+
+// sum computes the total size, item count and dir-ness of path, recursing
+// into subdirectories and consulting cache along the way.
+func sum(path string, cache *Cache) (size int64, count int, isDir bool, err error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		return info.Size(), 1, false, nil
+	}
+
+	if !info.IsDir() {
+		return info.Size(), 1, false, nil
+	}
+
+	if key, ok := statKey(info); ok {
+		if cached, ok := cache.lookup(key); ok {
+			return cached.Size, cached.ItemCount, true, nil
+		}
+		defer func() {
+			if err == nil {
+				cache.store(key, Result{Size: size, ItemCount: count, IsDir: true})
+			}
+		}()
+	}
+
+	entries, readErr := os.ReadDir(path)
+	if readErr != nil {
+		// Permission errors on a subdirectory shouldn't fail the whole walk;
+		// report it as an empty directory.
+		return 0, 0, true, nil
+	}
+
+	for _, entry := range entries {
+		childSize, childCount, _, childErr := sum(filepath.Join(path, entry.Name()), cache)
+		if childErr != nil {
+			continue
+		}
+		size += childSize
+		count += childCount
+	}
+	count++ // the directory itself
+	return size, count, true, nil
+}